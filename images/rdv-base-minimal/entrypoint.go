@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -13,9 +14,14 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
+	"sync"
 	"syscall"
 	"time"
+
+	"github.com/lintility/random.dev/images/rdv-base-minimal/materials"
 )
 
 const specVersion = "0.1"
@@ -51,10 +57,12 @@ type attestation struct {
 	Builder      attestationBuilder            `json:"builder"`
 	Materials    map[string]string             `json:"materials"`
 	Products     map[string]attestationProduct `json:"products"`
+	ProductsRoot string                        `json:"products_root,omitempty"`
 	ExitCode     int                           `json:"exit_code"`
 	StartedAt    string                        `json:"started_at"`
 	FinishedAt   string                        `json:"finished_at"`
 	Signature    interface{}                   `json:"signature"`
+	Stats        *attestationStats             `json:"stats,omitempty"`
 }
 
 type attestationTool struct {
@@ -72,72 +80,225 @@ type attestationProduct struct {
 	Path   string `json:"path"`
 }
 
-func hashFile(path string) (string, error) {
+// attestationStats surfaces counters for optional subsystems — today
+// just the hash cache — so users can see e.g. the cache hit rate that
+// made a run fast instead of having to infer it from wall-clock time.
+type attestationStats struct {
+	CacheMode                  string `json:"cache_mode"`
+	CacheHits                  int64  `json:"cache_hits"`
+	CacheMisses                int64  `json:"cache_misses"`
+	CacheCorruptEntriesDropped int64  `json:"cache_corrupt_entries_dropped,omitempty"`
+}
+
+func hashFileRaw(path string) ([]byte, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer f.Close()
 	h := sha256.New()
 	if _, err := io.Copy(h, f); err != nil {
-		return "", err
+		return nil, err
 	}
-	return hex.EncodeToString(h.Sum(nil)), nil
+	return h.Sum(nil), nil
 }
 
-func hashDir(root string) (string, error) {
-	type entry struct {
-		rel  string
-		hash string
+// emptyTreeHash is sha256("") and is what hashDir/materials roots
+// collapse to when a tree has no leaves, matching the pre-Merkle
+// behavior of hashing an empty set of files.
+const emptyTreeHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// parallelHashThreshold is the file count above which hashFiles switches
+// from a plain serial loop to the worker pool. Below it, goroutine and
+// channel overhead costs more than the hashing it would parallelize.
+const parallelHashThreshold = 100
+
+// hashWorkerCount returns the number of hashing goroutines to run,
+// overridable via TOOL_HASH_WORKERS for environments that want to cap
+// (or raise) it relative to runtime.NumCPU.
+func hashWorkerCount() int {
+	if v := os.Getenv("TOOL_HASH_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
 	}
-	var entries []entry
+	return runtime.NumCPU()
+}
+
+// walkedFile is a file discovered while walking a tree, before hashing.
+type walkedFile struct {
+	rel string
+	abs string
+}
 
+// walkFiles walks root and returns every regular file found, skipping
+// any whose root-relative path satisfies skip.
+func walkFiles(root string, skip func(rel string) bool) ([]walkedFile, error) {
+	var files []walkedFile
 	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil || d.IsDir() {
 			return err
 		}
 		rel, _ := filepath.Rel(root, path)
-		fh, err := hashFile(path)
-		if err != nil {
-			return err
+		if skip != nil && skip(rel) {
+			return nil
 		}
-		entries = append(entries, entry{rel: rel, hash: fh})
+		files = append(files, walkedFile{rel: rel, abs: path})
 		return nil
 	})
+	return files, err
+}
+
+// hashFiles hashes files, serially for small trees and across a bounded
+// worker pool (hashWorkerCount goroutines) once the tree is large enough
+// that the parallelism pays for itself. files are enqueued onto a
+// buffered channel for the pool to drain; a collector gathers results
+// and sorts them by RelPath before returning, so the resulting leaf
+// order — and any root built from it — is identical to the serial path.
+// If any worker fails, the pool is cancelled via context and the first
+// error is returned.
+func hashFiles(files []walkedFile, cache *hashCache) ([]materials.Leaf, error) {
+	if len(files) <= parallelHashThreshold {
+		leaves := make([]materials.Leaf, 0, len(files))
+		for _, f := range files {
+			h, err := cache.hash(f.abs)
+			if err != nil {
+				return nil, err
+			}
+			leaves = append(leaves, materials.Leaf{RelPath: f.rel, ContentSHA256: h})
+		}
+		sort.Slice(leaves, func(i, j int) bool { return leaves[i].RelPath < leaves[j].RelPath })
+		return leaves, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	paths := make(chan walkedFile, len(files))
+	for _, f := range files {
+		paths <- f
+	}
+	close(paths)
+
+	type result struct {
+		leaf materials.Leaf
+		err  error
+	}
+	results := make(chan result, len(files))
+
+	var wg sync.WaitGroup
+	for i := 0; i < hashWorkerCount(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range paths {
+				if ctx.Err() != nil {
+					return
+				}
+				h, err := cache.hash(f.abs)
+				if err != nil {
+					results <- result{err: err}
+					cancel()
+					return
+				}
+				results <- result{leaf: materials.Leaf{RelPath: f.rel, ContentSHA256: h}}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	leaves := make([]materials.Leaf, 0, len(files))
+	var firstErr error
+	for r := range results {
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		leaves = append(leaves, r.leaf)
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	sort.Slice(leaves, func(i, j int) bool { return leaves[i].RelPath < leaves[j].RelPath })
+	return leaves, nil
+}
+
+// hashDir builds a Merkle tree over the sorted (relpath, content sha256)
+// leaves found under root, writes the tree's layers to sidecarPath as a
+// materials.Sidecar so a verifier can later prove a single file was part
+// of this workspace, and returns the tree root (today's
+// materials["workspace"] value).
+func hashDir(root, sidecarPath string, cache *hashCache) (string, error) {
+	files, err := walkFiles(root, nil)
 	if err != nil {
 		return "", err
 	}
+	if len(files) == 0 {
+		return emptyTreeHash, nil
+	}
 
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].rel < entries[j].rel
-	})
+	leaves, err := hashFiles(files, cache)
+	if err != nil {
+		return "", err
+	}
 
-	h := sha256.New()
-	for _, e := range entries {
-		fmt.Fprintf(h, "%s:%s\n", e.rel, e.hash)
+	tree, err := materials.Build(leaves)
+	if err != nil {
+		return "", err
 	}
-	return hex.EncodeToString(h.Sum(nil)), nil
+
+	sidecar, err := json.MarshalIndent(tree.ToSidecar(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(sidecarPath, sidecar, 0644); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(tree.Root()), nil
 }
 
-func collectProducts(outputDir, toolName, invocationID string) map[string]attestationProduct {
-	products := make(map[string]attestationProduct)
-	_ = filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil || d.IsDir() {
-			return err
-		}
-		if filepath.Base(path) == ".attestation.json" {
-			return nil
-		}
-		rel, _ := filepath.Rel(outputDir, path)
-		h, err := hashFile(path)
-		if err != nil {
-			logJSON("warn", toolName, invocationID, fmt.Sprintf("Could not hash product %s: %v", rel, err))
-			return nil
-		}
-		products[rel] = attestationProduct{SHA256: h, Path: path}
-		return nil
+// collectProducts walks outputDir the same way hashDir walks the
+// workspace and additionally returns the Merkle root over the collected
+// products (stored as attestation.ProductsRoot), so products get the
+// same per-file inclusion proofs as materials.
+func collectProducts(outputDir, toolName, invocationID string, cache *hashCache) (map[string]attestationProduct, string) {
+	files, err := walkFiles(outputDir, func(rel string) bool {
+		base := filepath.Base(rel)
+		return base == ".attestation.json" || base == ".materials-tree.json"
 	})
-	return products
+	if err != nil {
+		logJSON("warn", toolName, invocationID, fmt.Sprintf("Could not walk output dir: %v", err))
+	}
+
+	products := make(map[string]attestationProduct, len(files))
+	leaves, err := hashFiles(files, cache)
+	if err != nil {
+		logJSON("warn", toolName, invocationID, fmt.Sprintf("Could not hash products: %v", err))
+		return products, ""
+	}
+	for _, l := range leaves {
+		products[l.RelPath] = attestationProduct{
+			SHA256: hex.EncodeToString(l.ContentSHA256),
+			Path:   filepath.Join(outputDir, l.RelPath),
+		}
+	}
+
+	if len(leaves) == 0 {
+		return products, ""
+	}
+	tree, err := materials.Build(leaves)
+	if err != nil {
+		logJSON("warn", toolName, invocationID, fmt.Sprintf("Could not build products Merkle tree: %v", err))
+		return products, ""
+	}
+	return products, hex.EncodeToString(tree.Root())
 }
 
 // ── Contract validation ───────────────────────────────────────────────────────
@@ -181,9 +342,46 @@ func validateContract(tool, invocationID string) error {
 	return nil
 }
 
+// nativeAttestationBytes marshals att, signs it per TOOL_SIGNING_MODE,
+// and re-marshals with the resulting Signature populated. This is the
+// default output format; see inTotoAttestationBytes for the alternate
+// TOOL_ATTESTATION_FORMAT=in-toto path.
+func nativeAttestationBytes(att *attestation, toolName, invocationID string) ([]byte, error) {
+	b, err := json.MarshalIndent(*att, "", "  ")
+	if err != nil {
+		logJSON("error", toolName, invocationID, "Failed to marshal attestation: "+err.Error())
+		return nil, err
+	}
+
+	sig, err := signAttestation(b)
+	if err != nil {
+		logJSON("error", toolName, invocationID, "Failed to sign attestation: "+err.Error())
+		return nil, err
+	}
+	if sig == nil {
+		return b, nil
+	}
+
+	att.Signature = sig
+	b, err = json.MarshalIndent(*att, "", "  ")
+	if err != nil {
+		logJSON("error", toolName, invocationID, "Failed to marshal signed attestation: "+err.Error())
+		return nil, err
+	}
+	return b, nil
+}
+
 // ── Main ──────────────────────────────────────────────────────────────────────
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		if err := cmdVerify(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "rdv verify: "+err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	invocationID := os.Getenv("TOOL_INVOCATION_ID")
 	if invocationID == "" {
 		invocationID = "unknown"
@@ -195,9 +393,11 @@ func main() {
 
 	toolName := "unknown"
 	toolVersion := "unknown"
+	var toolManifest map[string]interface{}
 	if raw, err := os.ReadFile("/tool-manifest.json"); err == nil {
 		var m map[string]interface{}
 		if json.Unmarshal(raw, &m) == nil {
+			toolManifest = m
 			if v, ok := m["name"].(string); ok {
 				toolName = v
 			}
@@ -215,8 +415,21 @@ func main() {
 	}
 	logJSON("info", toolName, invocationID, "Contract validated")
 
+	if os.Getenv("TOOL_MODE") == "replay" {
+		os.Exit(runReplay(toolName, invocationID))
+	}
+
+	args := os.Args[1:]
+	if mode, rest := extractCacheFlag(args); mode != "" {
+		os.Setenv("TOOL_CACHE_MODE", mode)
+		args = rest
+	}
+	cache := newHashCache()
+	defer cache.close()
+
 	startedAt := time.Now().UTC()
-	workspaceHash, _ := hashDir(os.Getenv("TOOL_WORKSPACE"))
+	outputDir := os.Getenv("TOOL_OUTPUT")
+	workspaceHash, _ := hashDir(os.Getenv("TOOL_WORKSPACE"), filepath.Join(outputDir, ".materials-tree.json"), cache)
 
 	candidates := []string{"/tool", "/tool.bin"}
 	toolBin := ""
@@ -231,7 +444,6 @@ func main() {
 		os.Exit(2)
 	}
 
-	args := os.Args[1:]
 	cmd := exec.Command(toolBin, args...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
@@ -251,8 +463,7 @@ func main() {
 	}
 
 	finishedAt := time.Now().UTC()
-	outputDir := os.Getenv("TOOL_OUTPUT")
-	products := collectProducts(outputDir, toolName, invocationID)
+	products, productsRoot := collectProducts(outputDir, toolName, invocationID, cache)
 
 	att := attestation{
 		SpecVersion:  specVersion,
@@ -268,19 +479,28 @@ func main() {
 		Materials: map[string]string{
 			"workspace": workspaceHash,
 		},
-		Products:   products,
-		ExitCode:   exitCode,
-		StartedAt:  startedAt.Format(time.RFC3339Nano),
-		FinishedAt: finishedAt.Format(time.RFC3339Nano),
-		Signature:  nil,
+		Products:     products,
+		ProductsRoot: productsRoot,
+		ExitCode:     exitCode,
+		StartedAt:    startedAt.Format(time.RFC3339Nano),
+		FinishedAt:   finishedAt.Format(time.RFC3339Nano),
+		Signature:    nil,
+		Stats:        cache.statsSnapshot(),
 	}
 
 	attPath := filepath.Join(outputDir, ".attestation.json")
-	b, err := json.MarshalIndent(att, "", "  ")
+
+	var b []byte
+	var err error
+	if os.Getenv("TOOL_ATTESTATION_FORMAT") == "in-toto" {
+		b, err = inTotoAttestationBytes(att, toolManifest, toolName, invocationID)
+	} else {
+		b, err = nativeAttestationBytes(&att, toolName, invocationID)
+	}
 	if err != nil {
-		logJSON("error", toolName, invocationID, "Failed to marshal attestation: "+err.Error())
 		os.Exit(2)
 	}
+
 	if err := os.WriteFile(attPath, b, 0644); err != nil {
 		logJSON("error", toolName, invocationID, "Contract violation: failed to write attestation to "+attPath+": "+err.Error())
 		os.Exit(2)
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/lintility/random.dev/images/rdv-base-minimal/materials"
+)
+
+// synthWorkspace writes n small files under a fresh temp directory and
+// returns its path, for use as a hashFiles/hashDir fixture.
+func synthWorkspace(tb testing.TB, n int) string {
+	tb.Helper()
+	dir := tb.TempDir()
+	for i := 0; i < n; i++ {
+		p := filepath.Join(dir, fmt.Sprintf("file-%d.txt", i))
+		if err := os.WriteFile(p, []byte(fmt.Sprintf("content-%d", i)), 0644); err != nil {
+			tb.Fatalf("writing synthetic file: %v", err)
+		}
+	}
+	return dir
+}
+
+// directLeaves hashes files with hashFileRaw one at a time and returns
+// the result sorted by RelPath, bypassing hashFiles' serial/parallel
+// split entirely. It's the ground truth the hashFiles tests below
+// compare against.
+func directLeaves(tb testing.TB, files []walkedFile) []materials.Leaf {
+	tb.Helper()
+	leaves := make([]materials.Leaf, len(files))
+	for i, f := range files {
+		h, err := hashFileRaw(f.abs)
+		if err != nil {
+			tb.Fatalf("hashFileRaw(%s): %v", f.abs, err)
+		}
+		leaves[i] = materials.Leaf{RelPath: f.rel, ContentSHA256: h}
+	}
+	sort.Slice(leaves, func(i, j int) bool { return leaves[i].RelPath < leaves[j].RelPath })
+	return leaves
+}
+
+func assertLeavesEqual(t *testing.T, got, want []materials.Leaf) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d leaves, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].RelPath != want[i].RelPath {
+			t.Fatalf("leaf %d: got path %q, want %q", i, got[i].RelPath, want[i].RelPath)
+		}
+		if string(got[i].ContentSHA256) != string(want[i].ContentSHA256) {
+			t.Fatalf("leaf %d (%s): hash mismatch", i, got[i].RelPath)
+		}
+	}
+}
+
+// TestHashFilesSerialMatchesDirect exercises the serial path (file count
+// at or below parallelHashThreshold).
+func TestHashFilesSerialMatchesDirect(t *testing.T) {
+	dir := synthWorkspace(t, parallelHashThreshold-1)
+	files, err := walkFiles(dir, nil)
+	if err != nil {
+		t.Fatalf("walkFiles: %v", err)
+	}
+
+	got, err := hashFiles(files, nil)
+	if err != nil {
+		t.Fatalf("hashFiles: %v", err)
+	}
+	assertLeavesEqual(t, got, directLeaves(t, files))
+}
+
+// TestHashFilesParallelMatchesDirect exercises the worker-pool path
+// (file count above parallelHashThreshold) and checks its output is
+// bit-identical to hashing every file directly, confirming the pool
+// doesn't reorder or corrupt results relative to the serial path.
+func TestHashFilesParallelMatchesDirect(t *testing.T) {
+	dir := synthWorkspace(t, parallelHashThreshold+50)
+	files, err := walkFiles(dir, nil)
+	if err != nil {
+		t.Fatalf("walkFiles: %v", err)
+	}
+
+	got, err := hashFiles(files, nil)
+	if err != nil {
+		t.Fatalf("hashFiles: %v", err)
+	}
+	assertLeavesEqual(t, got, directLeaves(t, files))
+}
+
+func benchmarkHashDir(b *testing.B, n int) {
+	dir := synthWorkspace(b, n)
+	sidecar := filepath.Join(b.TempDir(), ".materials-tree.json")
+	cache := &hashCache{mode: "off"}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := hashDir(dir, sidecar, cache); err != nil {
+			b.Fatalf("hashDir: %v", err)
+		}
+	}
+}
+
+func BenchmarkHashDir10Files(b *testing.B)     { benchmarkHashDir(b, 10) }
+func BenchmarkHashDir1000Files(b *testing.B)   { benchmarkHashDir(b, 1_000) }
+func BenchmarkHashDir100000Files(b *testing.B) { benchmarkHashDir(b, 100_000) }
@@ -0,0 +1,213 @@
+// Incremental hashing via an on-disk content-addressable cache.
+//
+// The cache is keyed by (absolute path, mtime_ns, size, inode), stored at
+// $TOOL_CACHE_DIR/rdv-hash-cache.db as an append-only log of
+// tab-separated records; the whole log is read into memory once at
+// startup and new entries are appended as they're produced.
+//
+// TOOL_CACHE_MODE (or the --cache= flag stripped out of argv in main)
+// selects the mode:
+//
+//	off   - bypass the cache entirely
+//	read  - consult the cache, never write to it
+//	write - never consult the cache, but record new entries
+//	rw    - consult and record (the default)
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+type cacheKey struct {
+	Path    string
+	MtimeNS int64
+	Size    int64
+	Inode   uint64
+}
+
+type hashCache struct {
+	mode string
+	path string
+	file *os.File
+
+	mu             sync.Mutex
+	entries        map[cacheKey][]byte
+	hits, misses   int64
+	corruptDropped int64
+}
+
+// newHashCache builds a cache from TOOL_CACHE_MODE (default "rw") and
+// TOOL_CACHE_DIR. With no TOOL_CACHE_DIR set, caching is disabled.
+func newHashCache() *hashCache {
+	mode := os.Getenv("TOOL_CACHE_MODE")
+	if mode == "" {
+		mode = "rw"
+	}
+	c := &hashCache{mode: mode, entries: make(map[cacheKey][]byte)}
+	if mode == "off" {
+		return c
+	}
+
+	dir := os.Getenv("TOOL_CACHE_DIR")
+	if dir == "" {
+		c.mode = "off"
+		return c
+	}
+	c.path = filepath.Join(dir, "rdv-hash-cache.db")
+
+	if mode == "read" || mode == "rw" {
+		c.load()
+	}
+	if mode == "write" || mode == "rw" {
+		if err := os.MkdirAll(dir, 0755); err == nil {
+			if f, err := os.OpenFile(c.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+				c.file = f
+			}
+		}
+	}
+	return c
+}
+
+// load reads every record from the cache log into memory. Malformed
+// records are dropped rather than trusted.
+func (c *hashCache) load() {
+	raw, err := os.ReadFile(c.path)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(raw), "\n") {
+		if line == "" {
+			continue
+		}
+		key, hash, ok := parseCacheRecord(line)
+		if !ok {
+			c.corruptDropped++
+			continue
+		}
+		c.entries[key] = hash
+	}
+}
+
+func parseCacheRecord(line string) (cacheKey, []byte, bool) {
+	fields := strings.Split(line, "\t")
+	if len(fields) != 5 {
+		return cacheKey{}, nil, false
+	}
+	mtimeNS, errMtime := strconv.ParseInt(fields[1], 10, 64)
+	size, errSize := strconv.ParseInt(fields[2], 10, 64)
+	inode, errInode := strconv.ParseUint(fields[3], 10, 64)
+	hash, errHash := hex.DecodeString(fields[4])
+	if errMtime != nil || errSize != nil || errInode != nil || errHash != nil || len(hash) != sha256.Size {
+		return cacheKey{}, nil, false
+	}
+	return cacheKey{Path: fields[0], MtimeNS: mtimeNS, Size: size, Inode: inode}, hash, true
+}
+
+// cacheKeyFor derives a cacheKey from a stat result. It reports false if
+// the inode isn't available via syscall.Stat_t.
+func cacheKeyFor(path string, info os.FileInfo) (cacheKey, bool) {
+	sys, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return cacheKey{}, false
+	}
+	return cacheKey{
+		Path:    path,
+		MtimeNS: sys.Mtim.Nano(),
+		Size:    info.Size(),
+		Inode:   sys.Ino,
+	}, true
+}
+
+// hash returns the sha256 of path, consulting and/or populating the
+// cache according to c.mode.
+func (c *hashCache) hash(path string) ([]byte, error) {
+	if c == nil || c.mode == "off" {
+		return hashFileRaw(path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	key, cacheable := cacheKeyFor(path, info)
+
+	if cacheable && (c.mode == "read" || c.mode == "rw") {
+		c.mu.Lock()
+		h, hit := c.entries[key]
+		if hit {
+			c.hits++
+		}
+		c.mu.Unlock()
+		if hit {
+			return h, nil
+		}
+	}
+
+	c.mu.Lock()
+	c.misses++
+	c.mu.Unlock()
+
+	h, err := hashFileRaw(path)
+	if err != nil {
+		return nil, err
+	}
+	if cacheable && (c.mode == "write" || c.mode == "rw") {
+		c.store(key, h)
+	}
+	return h, nil
+}
+
+func (c *hashCache) store(key cacheKey, hash []byte) {
+	c.mu.Lock()
+	c.entries[key] = hash
+	f := c.file
+	c.mu.Unlock()
+	if f == nil {
+		return
+	}
+	line := fmt.Sprintf("%s\t%d\t%d\t%d\t%s\n", key.Path, key.MtimeNS, key.Size, key.Inode, hex.EncodeToString(hash))
+	c.mu.Lock()
+	_, _ = f.WriteString(line)
+	c.mu.Unlock()
+}
+
+func (c *hashCache) close() {
+	if c != nil && c.file != nil {
+		c.file.Close()
+	}
+}
+
+func (c *hashCache) statsSnapshot() *attestationStats {
+	if c == nil || c.mode == "off" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &attestationStats{
+		CacheMode:                  c.mode,
+		CacheHits:                  c.hits,
+		CacheMisses:                c.misses,
+		CacheCorruptEntriesDropped: c.corruptDropped,
+	}
+}
+
+// extractCacheFlag pulls a "--cache=mode" argument out of args, if
+// present, so it isn't forwarded to the wrapped tool.
+func extractCacheFlag(args []string) (mode string, rest []string) {
+	for _, a := range args {
+		if v, ok := strings.CutPrefix(a, "--cache="); ok {
+			mode = v
+			continue
+		}
+		rest = append(rest, a)
+	}
+	return mode, rest
+}
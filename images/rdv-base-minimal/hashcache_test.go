@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHashCacheDetectsCorruption deliberately corrupts the cache's only
+// entry on disk and verifies that load() drops it (bumping
+// corruptDropped) rather than trusting it, so the next hash() call for
+// that file falls back to recomputing the digest from content instead
+// of returning the corrupted one.
+func TestHashCacheDetectsCorruption(t *testing.T) {
+	workDir := t.TempDir()
+	cacheDir := t.TempDir()
+	file := filepath.Join(workDir, "a.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatalf("writing fixture file: %v", err)
+	}
+
+	t.Setenv("TOOL_CACHE_DIR", cacheDir)
+	t.Setenv("TOOL_CACHE_MODE", "rw")
+
+	c := newHashCache()
+	want, err := c.hash(file)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	c.close()
+
+	raw, err := os.ReadFile(c.path)
+	if err != nil {
+		t.Fatalf("reading cache log: %v", err)
+	}
+	line := strings.TrimRight(string(raw), "\n")
+	fields := strings.Split(line, "\t")
+	if len(fields) != 5 {
+		t.Fatalf("cache log line has %d fields, want 5: %q", len(fields), line)
+	}
+	fields[4] = "not-a-valid-sha256" // corrupt the stored digest
+	corrupted := strings.Join(fields, "\t") + "\n"
+	if err := os.WriteFile(c.path, []byte(corrupted), 0644); err != nil {
+		t.Fatalf("writing corrupted cache log: %v", err)
+	}
+
+	c2 := newHashCache()
+	defer c2.close()
+	if c2.corruptDropped != 1 {
+		t.Fatalf("corruptDropped = %d, want 1", c2.corruptDropped)
+	}
+
+	got, err := c2.hash(file)
+	if err != nil {
+		t.Fatalf("hash after corruption: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("hash after corruption = %x, want %x (should have recomputed from content)", got, want)
+	}
+	if c2.hits != 0 || c2.misses != 1 {
+		t.Fatalf("hits=%d misses=%d, want hits=0 misses=1 (corrupted entry must not count as a hit)", c2.hits, c2.misses)
+	}
+}
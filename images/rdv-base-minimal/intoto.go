@@ -0,0 +1,197 @@
+// Alternate, in-toto / SLSA-provenance-compatible attestation output.
+//
+// Selected via TOOL_ATTESTATION_FORMAT=in-toto (the native format stays
+// the default). Produces an in-toto v1 Statement whose predicate is an
+// SLSA Provenance v1 document, built from the same data already
+// collected into the native attestation struct. When signing is
+// enabled the statement is wrapped in a DSSE envelope instead of
+// carrying an embedded Signature field.
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+const (
+	inTotoStatementType = "https://in-toto.io/Statement/v1"
+	slsaPredicateType   = "https://slsa.dev/provenance/v1"
+	slsaBuildType       = "https://lintility.dev/rdv/buildtypes/entrypoint/v1"
+	dssePayloadType     = "application/vnd.in-toto+json"
+)
+
+type inTotoStatement struct {
+	Type          string          `json:"_type"`
+	Subject       []inTotoSubject `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     slsaProvenance  `json:"predicate"`
+}
+
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type slsaResourceDescriptor struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest"`
+}
+
+type slsaProvenance struct {
+	BuildDefinition slsaBuildDefinition `json:"buildDefinition"`
+	RunDetails      slsaRunDetails      `json:"runDetails"`
+}
+
+type slsaBuildDefinition struct {
+	BuildType            string                   `json:"buildType"`
+	ExternalParameters   map[string]interface{}   `json:"externalParameters"`
+	InternalParameters   map[string]interface{}   `json:"internalParameters,omitempty"`
+	ResolvedDependencies []slsaResourceDescriptor `json:"resolvedDependencies,omitempty"`
+}
+
+type slsaRunDetails struct {
+	Builder    slsaBuilder              `json:"builder"`
+	Metadata   slsaMetadata             `json:"metadata"`
+	Byproducts []slsaResourceDescriptor `json:"byproducts,omitempty"`
+}
+
+type slsaBuilder struct {
+	ID string `json:"id"`
+}
+
+type slsaMetadata struct {
+	InvocationID string `json:"invocationId"`
+	StartedOn    string `json:"startedOn"`
+	FinishedOn   string `json:"finishedOn"`
+}
+
+// buildInTotoStatement derives an in-toto Statement from an already
+// populated native attestation.
+func buildInTotoStatement(att attestation, toolManifest map[string]interface{}) inTotoStatement {
+	subjects := make([]inTotoSubject, 0, len(att.Products))
+	for name, p := range att.Products {
+		subjects = append(subjects, inTotoSubject{Name: name, Digest: map[string]string{"sha256": p.SHA256}})
+	}
+	sort.Slice(subjects, func(i, j int) bool { return subjects[i].Name < subjects[j].Name })
+
+	deps := make([]slsaResourceDescriptor, 0, len(att.Materials))
+	for uri, sha := range att.Materials {
+		deps = append(deps, slsaResourceDescriptor{URI: uri, Digest: map[string]string{"sha256": sha}})
+	}
+	sort.Slice(deps, func(i, j int) bool { return deps[i].URI < deps[j].URI })
+
+	externalParams := toolManifest
+	if externalParams == nil {
+		externalParams = map[string]interface{}{}
+	}
+
+	return inTotoStatement{
+		Type:          inTotoStatementType,
+		Subject:       subjects,
+		PredicateType: slsaPredicateType,
+		Predicate: slsaProvenance{
+			BuildDefinition: slsaBuildDefinition{
+				BuildType:            slsaBuildType,
+				ExternalParameters:   externalParams,
+				ResolvedDependencies: deps,
+			},
+			RunDetails: slsaRunDetails{
+				Builder: slsaBuilder{ID: att.Builder.ID},
+				Metadata: slsaMetadata{
+					InvocationID: att.InvocationID,
+					StartedOn:    att.StartedAt,
+					FinishedOn:   att.FinishedAt,
+				},
+			},
+		},
+	}
+}
+
+// dsseEnvelope is a DSSE envelope (https://github.com/secure-systems-lab/dsse).
+type dsseEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     string          `json:"payload"`
+	Signatures  []dsseSignature `json:"signatures"`
+}
+
+// dsseSignature carries the DSSE-required sig alongside the same signing
+// metadata the native format embeds in attestation.Signature.
+type dsseSignature struct {
+	Sig         string     `json:"sig"`
+	Mode        string     `json:"mode,omitempty"`
+	Algorithm   string     `json:"algorithm,omitempty"`
+	Certificate string     `json:"certificate,omitempty"`
+	Tlog        *tlogEntry `json:"tlog,omitempty"`
+}
+
+// preAuthenticationEncoding implements the DSSE PAE
+// (https://github.com/secure-systems-lab/dsse/blob/master/protocol.md#signature-definition):
+//
+//	PAE(type, body) = "DSSEv1" + SP + LEN(type) + SP + type + SP + LEN(body) + SP + body
+func preAuthenticationEncoding(payloadType string, payload []byte) []byte {
+	pae := make([]byte, 0, len(payloadType)+len(payload)+32)
+	pae = append(pae, "DSSEv1 "...)
+	pae = append(pae, strconv.Itoa(len(payloadType))...)
+	pae = append(pae, ' ')
+	pae = append(pae, payloadType...)
+	pae = append(pae, ' ')
+	pae = append(pae, strconv.Itoa(len(payload))...)
+	pae = append(pae, ' ')
+	pae = append(pae, payload...)
+	return pae
+}
+
+// wrapDSSE wraps payload and its signature in a DSSE envelope.
+func wrapDSSE(payload []byte, sig interface{}) (dsseEnvelope, error) {
+	s, ok := sig.(*signature)
+	if !ok {
+		return dsseEnvelope{}, fmt.Errorf("intoto: unexpected signature type %T", sig)
+	}
+	return dsseEnvelope{
+		PayloadType: dssePayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures: []dsseSignature{{
+			Sig:         s.Value,
+			Mode:        s.Mode,
+			Algorithm:   s.Algorithm,
+			Certificate: s.Certificate,
+			Tlog:        s.Tlog,
+		}},
+	}, nil
+}
+
+// inTotoAttestationBytes builds an in-toto Statement from att, signs it
+// per TOOL_SIGNING_MODE, and wraps it in a DSSE envelope when signing
+// produced a signature.
+func inTotoAttestationBytes(att attestation, toolManifest map[string]interface{}, toolName, invocationID string) ([]byte, error) {
+	stmt := buildInTotoStatement(att, toolManifest)
+	payload, err := json.MarshalIndent(stmt, "", "  ")
+	if err != nil {
+		logJSON("error", toolName, invocationID, "Failed to marshal in-toto statement: "+err.Error())
+		return nil, err
+	}
+
+	sig, err := signAttestation(preAuthenticationEncoding(dssePayloadType, payload))
+	if err != nil {
+		logJSON("error", toolName, invocationID, "Failed to sign in-toto statement: "+err.Error())
+		return nil, err
+	}
+	if sig == nil {
+		return payload, nil
+	}
+
+	env, err := wrapDSSE(payload, sig)
+	if err != nil {
+		logJSON("error", toolName, invocationID, "Failed to build DSSE envelope: "+err.Error())
+		return nil, err
+	}
+	b, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		logJSON("error", toolName, invocationID, "Failed to marshal DSSE envelope: "+err.Error())
+		return nil, err
+	}
+	return b, nil
+}
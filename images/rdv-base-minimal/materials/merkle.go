@@ -0,0 +1,161 @@
+// Package materials builds a binary Merkle tree over a set of files so
+// a verifier can prove a single file was part of a recorded workspace
+// or product set without re-reading every file.
+//
+// Leaf hash:     SHA256(0x00 || varint(len(relpath)) || relpath || content_sha256)
+// Internal node: SHA256(0x01 || left || right)
+//
+// Odd node counts at a level promote the last node unchanged rather
+// than duplicating it RFC 6962-style.
+package materials
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// Leaf is a single (path, content digest) entry going into the tree.
+type Leaf struct {
+	RelPath       string
+	ContentSHA256 []byte
+}
+
+// Tree is a built Merkle tree. Layers[0] holds the leaf hashes in sorted
+// relpath order; the last layer holds the single root hash.
+type Tree struct {
+	Leaves []Leaf
+	Layers [][][]byte
+}
+
+func leafHash(l Leaf) []byte {
+	buf := make([]byte, 0, 1+binary.MaxVarintLen64+len(l.RelPath)+len(l.ContentSHA256))
+	buf = append(buf, 0x00)
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(l.RelPath)))
+	buf = append(buf, lenBuf[:n]...)
+	buf = append(buf, l.RelPath...)
+	buf = append(buf, l.ContentSHA256...)
+	h := sha256.Sum256(buf)
+	return h[:]
+}
+
+func nodeHash(left, right []byte) []byte {
+	buf := make([]byte, 0, 1+len(left)+len(right))
+	buf = append(buf, 0x01)
+	buf = append(buf, left...)
+	buf = append(buf, right...)
+	h := sha256.Sum256(buf)
+	return h[:]
+}
+
+// Build sorts leaves by RelPath and constructs the tree layer by layer.
+func Build(leaves []Leaf) (*Tree, error) {
+	if len(leaves) == 0 {
+		return nil, fmt.Errorf("materials: no leaves to build a tree from")
+	}
+
+	sorted := make([]Leaf, len(leaves))
+	copy(sorted, leaves)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].RelPath < sorted[j].RelPath })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].RelPath == sorted[i-1].RelPath {
+			return nil, fmt.Errorf("materials: duplicate path %q", sorted[i].RelPath)
+		}
+	}
+
+	layer := make([][]byte, len(sorted))
+	for i, l := range sorted {
+		layer[i] = leafHash(l)
+	}
+
+	layers := [][][]byte{layer}
+	for len(layer) > 1 {
+		next := make([][]byte, 0, (len(layer)+1)/2)
+		for i := 0; i < len(layer); i += 2 {
+			if i+1 < len(layer) {
+				next = append(next, nodeHash(layer[i], layer[i+1]))
+			} else {
+				next = append(next, layer[i])
+			}
+		}
+		layers = append(layers, next)
+		layer = next
+	}
+
+	return &Tree{Leaves: sorted, Layers: layers}, nil
+}
+
+// Root returns the root hash of the tree.
+func (t *Tree) Root() []byte {
+	last := t.Layers[len(t.Layers)-1]
+	return last[0]
+}
+
+func (t *Tree) indexOf(relpath string) int {
+	for i, l := range t.Leaves {
+		if l.RelPath == relpath {
+			return i
+		}
+	}
+	return -1
+}
+
+// ProofStep is one sibling hash on the path from a leaf to the root.
+type ProofStep struct {
+	Hash       []byte
+	OnTheRight bool // true if Hash is the right-hand node at this level
+}
+
+// Prove returns the sibling hashes from the leaf for relpath up to the root.
+func (t *Tree) Prove(relpath string) ([]ProofStep, error) {
+	idx := t.indexOf(relpath)
+	if idx < 0 {
+		return nil, fmt.Errorf("materials: %q is not in the tree", relpath)
+	}
+
+	var proof []ProofStep
+	for _, layer := range t.Layers[:len(t.Layers)-1] {
+		if idx%2 == 0 {
+			if idx+1 < len(layer) {
+				proof = append(proof, ProofStep{Hash: layer[idx+1], OnTheRight: true})
+			}
+			// odd count, last node promoted: no sibling to record
+		} else {
+			proof = append(proof, ProofStep{Hash: layer[idx-1], OnTheRight: false})
+		}
+		idx /= 2
+	}
+	return proof, nil
+}
+
+// Verify recomputes the root from leafHash and proof and reports whether it matches root.
+func Verify(leafHash []byte, proof []ProofStep, root []byte) bool {
+	cur := leafHash
+	for _, step := range proof {
+		if step.OnTheRight {
+			cur = nodeHash(cur, step.Hash)
+		} else {
+			cur = nodeHash(step.Hash, cur)
+		}
+	}
+	return equalBytes(cur, root)
+}
+
+func equalBytes(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// LeafHash exposes the leaf hashing function for verification outside of a Tree.
+func LeafHash(relpath string, contentSHA256 []byte) []byte {
+	return leafHash(Leaf{RelPath: relpath, ContentSHA256: contentSHA256})
+}
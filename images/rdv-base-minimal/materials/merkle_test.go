@@ -0,0 +1,122 @@
+package materials
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func leavesFor(names ...string) []Leaf {
+	leaves := make([]Leaf, len(names))
+	for i, n := range names {
+		h := sha256.Sum256([]byte(n))
+		leaves[i] = Leaf{RelPath: n, ContentSHA256: h[:]}
+	}
+	return leaves
+}
+
+func TestBuildProveVerifyEvenLeafCount(t *testing.T) {
+	leaves := leavesFor("a", "b", "c", "d")
+	tree, err := Build(leaves)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	for _, l := range leaves {
+		proof, err := tree.Prove(l.RelPath)
+		if err != nil {
+			t.Fatalf("Prove(%s): %v", l.RelPath, err)
+		}
+		if !Verify(LeafHash(l.RelPath, l.ContentSHA256), proof, tree.Root()) {
+			t.Fatalf("Verify(%s) failed against a correct proof", l.RelPath)
+		}
+	}
+}
+
+func TestBuildProveVerifyOddLeafCount(t *testing.T) {
+	leaves := leavesFor("a", "b", "c")
+	tree, err := Build(leaves)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	for _, l := range leaves {
+		proof, err := tree.Prove(l.RelPath)
+		if err != nil {
+			t.Fatalf("Prove(%s): %v", l.RelPath, err)
+		}
+		if !Verify(LeafHash(l.RelPath, l.ContentSHA256), proof, tree.Root()) {
+			t.Fatalf("Verify(%s) failed against a correct proof", l.RelPath)
+		}
+	}
+}
+
+func TestVerifyRejectsTamperedLeaf(t *testing.T) {
+	leaves := leavesFor("a", "b", "c")
+	tree, err := Build(leaves)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	proof, err := tree.Prove("b")
+	if err != nil {
+		t.Fatalf("Prove: %v", err)
+	}
+
+	tamperedHash := sha256.Sum256([]byte("not-b"))
+	if Verify(tamperedHash[:], proof, tree.Root()) {
+		t.Fatal("Verify accepted a proof for the wrong leaf content")
+	}
+}
+
+func TestVerifyRejectsMismatchedProof(t *testing.T) {
+	a, err := Build(leavesFor("a", "b", "c"))
+	if err != nil {
+		t.Fatalf("Build a: %v", err)
+	}
+	b, err := Build(leavesFor("x", "y", "z"))
+	if err != nil {
+		t.Fatalf("Build b: %v", err)
+	}
+
+	proofFromB, err := b.Prove("x")
+	if err != nil {
+		t.Fatalf("Prove: %v", err)
+	}
+
+	if Verify(LeafHash("a", leavesFor("a")[0].ContentSHA256), proofFromB, a.Root()) {
+		t.Fatal("Verify accepted a proof built against a different tree")
+	}
+}
+
+func TestBuildRejectsDuplicatePath(t *testing.T) {
+	if _, err := Build(leavesFor("a", "a")); err == nil {
+		t.Fatal("Build accepted duplicate paths")
+	}
+}
+
+func TestBuildRejectsEmpty(t *testing.T) {
+	if _, err := Build(nil); err == nil {
+		t.Fatal("Build accepted an empty leaf set")
+	}
+}
+
+func TestToSidecarMatchesTree(t *testing.T) {
+	leaves := leavesFor("a", "b", "c")
+	tree, err := Build(leaves)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	sidecar := tree.ToSidecar()
+	if len(sidecar.Leaves) != len(leaves) || len(sidecar.ContentSHA256) != len(leaves) {
+		t.Fatalf("sidecar has %d leaves / %d content hashes, want %d", len(sidecar.Leaves), len(sidecar.ContentSHA256), len(leaves))
+	}
+	for i := 1; i < len(sidecar.Leaves); i++ {
+		if sidecar.Leaves[i-1] >= sidecar.Leaves[i] {
+			t.Fatalf("sidecar leaves not sorted: %q >= %q", sidecar.Leaves[i-1], sidecar.Leaves[i])
+		}
+	}
+	if sidecar.Layers[len(sidecar.Layers)-1][0] != sidecar.Root {
+		t.Fatalf("sidecar root %q does not match last layer %v", sidecar.Root, sidecar.Layers[len(sidecar.Layers)-1])
+	}
+}
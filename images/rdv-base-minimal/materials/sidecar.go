@@ -0,0 +1,41 @@
+package materials
+
+import "encoding/hex"
+
+// Sidecar is the JSON-serializable form of a Tree, written alongside an
+// attestation so a verifier can recompute Prove/Verify without re-hashing
+// the original files. ContentSHA256 is carried parallel to Leaves so
+// callers like replay mode (TOOL_MODE=replay) get at content digests without reversing a
+// leaf hash.
+type Sidecar struct {
+	Root          string     `json:"root"`
+	Leaves        []string   `json:"leaves"`         // relpaths, in the sorted order used to build the tree
+	ContentSHA256 []string   `json:"content_sha256"` // hex content digest per leaf, parallel to Leaves
+	Layers        [][]string `json:"layers"`         // hex-encoded hashes, leaves first, root last
+}
+
+// ToSidecar renders the tree as its JSON-serializable form.
+func (t *Tree) ToSidecar() Sidecar {
+	relpaths := make([]string, len(t.Leaves))
+	contentHashes := make([]string, len(t.Leaves))
+	for i, l := range t.Leaves {
+		relpaths[i] = l.RelPath
+		contentHashes[i] = hex.EncodeToString(l.ContentSHA256)
+	}
+
+	layers := make([][]string, len(t.Layers))
+	for i, layer := range t.Layers {
+		hexLayer := make([]string, len(layer))
+		for j, h := range layer {
+			hexLayer[j] = hex.EncodeToString(h)
+		}
+		layers[i] = hexLayer
+	}
+
+	return Sidecar{
+		Root:          hex.EncodeToString(t.Root()),
+		Leaves:        relpaths,
+		ContentSHA256: contentHashes,
+		Layers:        layers,
+	}
+}
@@ -0,0 +1,179 @@
+// Dry-run / replay mode.
+//
+// TOOL_MODE=replay skips executing the wrapped tool binary entirely.
+// Instead it reads a previously produced attestation from
+// TOOL_EXPECTED_ATTESTATION (plus its .materials-tree.json sidecar, read
+// from the same directory), recomputes workspace and product digests
+// from the current mounts, and reports whether the filesystem still
+// matches what was attested — useful for reproducibility checks and
+// cache-invalidation decisions in CI without re-running the tool.
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/lintility/random.dev/images/rdv-base-minimal/materials"
+)
+
+// replayDiffEntry is one added/removed/changed path in a replay diff.
+type replayDiffEntry struct {
+	Path      string `json:"path"`
+	OldSHA256 string `json:"old_sha256,omitempty"`
+	NewSHA256 string `json:"new_sha256,omitempty"`
+}
+
+// replayCategoryDiff holds the added/removed/changed paths for one
+// category (materials or products) of a replay comparison.
+type replayCategoryDiff struct {
+	Added   []replayDiffEntry `json:"added,omitempty"`
+	Removed []replayDiffEntry `json:"removed,omitempty"`
+	Changed []replayDiffEntry `json:"changed,omitempty"`
+}
+
+func (d replayCategoryDiff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// replayDiff is the structured diff emitted to stderr on a replay
+// mismatch.
+type replayDiff struct {
+	Materials replayCategoryDiff `json:"materials"`
+	Products  replayCategoryDiff `json:"products"`
+}
+
+func (d replayDiff) empty() bool {
+	return d.Materials.empty() && d.Products.empty()
+}
+
+// diffPathHashes compares expected and actual relpath->sha256 maps and
+// returns the added/removed/changed entries, sorted by path.
+func diffPathHashes(expected, actual map[string]string) replayCategoryDiff {
+	var d replayCategoryDiff
+	for path, newHash := range actual {
+		oldHash, ok := expected[path]
+		if !ok {
+			d.Added = append(d.Added, replayDiffEntry{Path: path, NewSHA256: newHash})
+		} else if oldHash != newHash {
+			d.Changed = append(d.Changed, replayDiffEntry{Path: path, OldSHA256: oldHash, NewSHA256: newHash})
+		}
+	}
+	for path, oldHash := range expected {
+		if _, ok := actual[path]; !ok {
+			d.Removed = append(d.Removed, replayDiffEntry{Path: path, OldSHA256: oldHash})
+		}
+	}
+
+	sort.Slice(d.Added, func(i, j int) bool { return d.Added[i].Path < d.Added[j].Path })
+	sort.Slice(d.Removed, func(i, j int) bool { return d.Removed[i].Path < d.Removed[j].Path })
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].Path < d.Changed[j].Path })
+	return d
+}
+
+// runReplay implements TOOL_MODE=replay. It returns the process exit
+// code: 0 if the current filesystem state matches the expected
+// attestation, 2 on a setup error (bad env, unreadable files), or 3 on a
+// digest mismatch.
+func runReplay(toolName, invocationID string) int {
+	expectedPath := os.Getenv("TOOL_EXPECTED_ATTESTATION")
+	if expectedPath == "" {
+		logJSON("error", toolName, invocationID, "TOOL_MODE=replay requires TOOL_EXPECTED_ATTESTATION")
+		return 2
+	}
+
+	expectedRaw, err := os.ReadFile(expectedPath)
+	if err != nil {
+		logJSON("error", toolName, invocationID, "Failed to read TOOL_EXPECTED_ATTESTATION: "+err.Error())
+		return 2
+	}
+
+	// An in-toto Statement, bare or wrapped in a DSSE envelope, shares no
+	// fields with attestation, so json.Unmarshal into it below would
+	// silently succeed with an empty expected.Products. Detect both up
+	// front.
+	var probe struct {
+		Type        string `json:"_type"`
+		PayloadType string `json:"payloadType"`
+	}
+	if err := json.Unmarshal(expectedRaw, &probe); err != nil {
+		logJSON("error", toolName, invocationID, "Failed to parse TOOL_EXPECTED_ATTESTATION: "+err.Error())
+		return 2
+	}
+	if probe.PayloadType != "" || probe.Type != "" {
+		logJSON("error", toolName, invocationID, "TOOL_EXPECTED_ATTESTATION is in in-toto format, not supported by replay")
+		return 2
+	}
+
+	var expected attestation
+	if err := json.Unmarshal(expectedRaw, &expected); err != nil {
+		logJSON("error", toolName, invocationID, "Failed to parse TOOL_EXPECTED_ATTESTATION: "+err.Error())
+		return 2
+	}
+
+	sidecarPath := filepath.Join(filepath.Dir(expectedPath), ".materials-tree.json")
+	sidecarRaw, err := os.ReadFile(sidecarPath)
+	if err != nil {
+		logJSON("error", toolName, invocationID, "Failed to read materials sidecar "+sidecarPath+": "+err.Error())
+		return 2
+	}
+	var expectedSidecar materials.Sidecar
+	if err := json.Unmarshal(sidecarRaw, &expectedSidecar); err != nil {
+		logJSON("error", toolName, invocationID, "Failed to parse materials sidecar: "+err.Error())
+		return 2
+	}
+	if len(expectedSidecar.ContentSHA256) != len(expectedSidecar.Leaves) {
+		logJSON("error", toolName, invocationID, "Materials sidecar "+sidecarPath+" has no content_sha256 per leaf (produced by an older rdv version?); cannot replay")
+		return 2
+	}
+
+	expectedMaterials := make(map[string]string, len(expectedSidecar.Leaves))
+	for i, rel := range expectedSidecar.Leaves {
+		expectedMaterials[rel] = expectedSidecar.ContentSHA256[i]
+	}
+
+	cache := newHashCache()
+	defer cache.close()
+
+	files, err := walkFiles(os.Getenv("TOOL_WORKSPACE"), nil)
+	if err != nil {
+		logJSON("error", toolName, invocationID, "Failed to walk workspace: "+err.Error())
+		return 2
+	}
+	leaves, err := hashFiles(files, cache)
+	if err != nil {
+		logJSON("error", toolName, invocationID, "Failed to hash workspace: "+err.Error())
+		return 2
+	}
+	actualMaterials := make(map[string]string, len(leaves))
+	for _, l := range leaves {
+		actualMaterials[l.RelPath] = hex.EncodeToString(l.ContentSHA256)
+	}
+
+	expectedProducts := make(map[string]string, len(expected.Products))
+	for rel, p := range expected.Products {
+		expectedProducts[rel] = p.SHA256
+	}
+	actualProductsMap, _ := collectProducts(os.Getenv("TOOL_OUTPUT"), toolName, invocationID, cache)
+	actualProducts := make(map[string]string, len(actualProductsMap))
+	for rel, p := range actualProductsMap {
+		actualProducts[rel] = p.SHA256
+	}
+
+	diff := replayDiff{
+		Materials: diffPathHashes(expectedMaterials, actualMaterials),
+		Products:  diffPathHashes(expectedProducts, actualProducts),
+	}
+
+	if diff.empty() {
+		logJSON("info", toolName, invocationID, "replay: current filesystem state matches the expected attestation")
+		return 0
+	}
+
+	b, _ := json.MarshalIndent(diff, "", "  ")
+	fmt.Fprintln(os.Stderr, string(b))
+	return 3
+}
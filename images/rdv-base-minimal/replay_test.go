@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunReplayRejectsInTotoExpectedAttestation(t *testing.T) {
+	keyPath := writeTestPGPKey(t)
+	t.Setenv("TOOL_SIGNING_MODE", "pgp")
+	t.Setenv("TOOL_SIGNING_KEY", keyPath)
+
+	att := attestation{SpecVersion: specVersion, Builder: attestationBuilder{ID: "rdv-local", TrustLevel: "local"}}
+	b, err := inTotoAttestationBytes(att, nil, "test-tool", "inv-1")
+	if err != nil {
+		t.Fatalf("inTotoAttestationBytes: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), ".attestation.json")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatalf("writing attestation: %v", err)
+	}
+
+	t.Setenv("TOOL_EXPECTED_ATTESTATION", path)
+	if code := runReplay("test-tool", "inv-1"); code != 2 {
+		t.Fatalf("runReplay returned %d for an in-toto expected attestation, want 2", code)
+	}
+}
@@ -0,0 +1,322 @@
+// Attestation signing, selected via TOOL_SIGNING_MODE: "none" (default),
+// "pgp", "x509", or "keyless".
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+type signature struct {
+	Mode        string     `json:"mode"`
+	Algorithm   string     `json:"algorithm"`
+	Value       string     `json:"value"`
+	Certificate string     `json:"certificate,omitempty"`
+	Tlog        *tlogEntry `json:"tlog,omitempty"`
+}
+
+// tlogEntry records a transparency-log inclusion for keyless signatures.
+type tlogEntry struct {
+	URL        string `json:"url"`
+	LogIndex   int64  `json:"log_index"`
+	LogID      string `json:"log_id"`
+	IntegrateD string `json:"integrated_time,omitempty"`
+}
+
+// signAttestation returns nil, nil if signing was not requested.
+func signAttestation(payload []byte) (interface{}, error) {
+	switch mode := os.Getenv("TOOL_SIGNING_MODE"); mode {
+	case "", "none":
+		return nil, nil
+	case "pgp":
+		return signPGP(payload)
+	case "x509":
+		return signX509(payload)
+	case "keyless":
+		return signKeyless(payload)
+	default:
+		return nil, fmt.Errorf("unknown TOOL_SIGNING_MODE: %s", mode)
+	}
+}
+
+func signPGP(payload []byte) (*signature, error) {
+	keyPath := os.Getenv("TOOL_SIGNING_KEY")
+	if keyPath == "" {
+		return nil, fmt.Errorf("TOOL_SIGNING_KEY not set for pgp signing")
+	}
+	f, err := os.Open(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening TOOL_SIGNING_KEY: %w", err)
+	}
+	defer f.Close()
+
+	block, err := armor.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decoding armored key: %w", err)
+	}
+	keyring, err := openpgp.ReadKeyRing(block.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading pgp keyring: %w", err)
+	}
+	if len(keyring) == 0 {
+		return nil, fmt.Errorf("no signing keys found in TOOL_SIGNING_KEY")
+	}
+
+	var sigBuf bytes.Buffer
+	if err := openpgp.DetachSign(&sigBuf, keyring[0], bytes.NewReader(payload), nil); err != nil {
+		return nil, fmt.Errorf("signing with pgp key: %w", err)
+	}
+
+	return &signature{
+		Mode:      "pgp",
+		Algorithm: "pgp",
+		Value:     base64.StdEncoding.EncodeToString(sigBuf.Bytes()),
+	}, nil
+}
+
+func signX509(payload []byte) (*signature, error) {
+	keyPath := os.Getenv("TOOL_SIGNING_KEY")
+	if keyPath == "" {
+		return nil, fmt.Errorf("TOOL_SIGNING_KEY not set for x509 signing")
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading TOOL_SIGNING_KEY: %w", err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in TOOL_SIGNING_KEY")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+
+	digest := sha256.Sum256(payload)
+	var (
+		sig []byte
+		alg string
+	)
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		sig, err = ecdsa.SignASN1(rand.Reader, k, digest[:])
+		alg = "ecdsa-p256-sha256"
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, payload)
+		alg = "ed25519"
+	default:
+		return nil, fmt.Errorf("unsupported key type %T for x509 signing", key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("signing with x509 key: %w", err)
+	}
+
+	// Required: verifyX509 has no raw-public-key path, only cert-based.
+	chainPath := os.Getenv("TOOL_SIGNING_CERT_CHAIN")
+	if chainPath == "" {
+		return nil, fmt.Errorf("TOOL_SIGNING_CERT_CHAIN not set for x509 signing")
+	}
+	certChainDER, err := loadCertChainDER(chainPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signature{
+		Mode:        "x509",
+		Algorithm:   alg,
+		Value:       base64.StdEncoding.EncodeToString(sig),
+		Certificate: base64.StdEncoding.EncodeToString(certChainDER),
+	}, nil
+}
+
+func loadCertChainDER(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading TOOL_SIGNING_CERT_CHAIN: %w", err)
+	}
+	var der []byte
+	for {
+		var block *pem.Block
+		block, raw = pem.Decode(raw)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			der = append(der, block.Bytes...)
+		}
+	}
+	return der, nil
+}
+
+// signKeyless: OIDC token -> ephemeral key -> Fulcio cert -> optional Rekor entry.
+func signKeyless(payload []byte) (*signature, error) {
+	oidcToken := os.Getenv("TOOL_OIDC_TOKEN")
+	if oidcToken == "" {
+		return nil, fmt.Errorf("TOOL_OIDC_TOKEN not set for keyless signing")
+	}
+	fulcioURL := os.Getenv("TOOL_FULCIO_URL")
+	if fulcioURL == "" {
+		return nil, fmt.Errorf("TOOL_FULCIO_URL not set for keyless signing")
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating ephemeral signing key: %w", err)
+	}
+
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "rdv-keyless"},
+	}, priv)
+	if err != nil {
+		return nil, fmt.Errorf("creating CSR: %w", err)
+	}
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER})
+
+	cert, err := requestFulcioCert(fulcioURL, oidcToken, csrPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, priv, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("signing with ephemeral key: %w", err)
+	}
+
+	out := &signature{
+		Mode:        "keyless",
+		Algorithm:   "ecdsa-p256-sha256",
+		Value:       base64.StdEncoding.EncodeToString(sig),
+		Certificate: base64.StdEncoding.EncodeToString(cert),
+	}
+
+	if rekorURL := os.Getenv("TOOL_REKOR_URL"); rekorURL != "" {
+		entry, err := recordRekorEntry(rekorURL, sig, cert, payload)
+		if err != nil {
+			return nil, err
+		}
+		out.Tlog = entry
+	}
+
+	return out, nil
+}
+
+func requestFulcioCert(fulcioURL, oidcToken string, csrPEM []byte) ([]byte, error) {
+	reqBody, err := json.Marshal(struct {
+		CertificateSigningRequest string `json:"certificateSigningRequest"`
+	}{CertificateSigningRequest: base64.StdEncoding.EncodeToString(csrPEM)})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling fulcio request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fulcioURL+"/api/v2/signingCert", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("building fulcio request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+oidcToken)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling fulcio: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading fulcio response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("fulcio returned %d: %s", resp.StatusCode, body)
+	}
+
+	var parsed struct {
+		SignedCertificateEmbeddedSct struct {
+			Chain []string `json:"chain"`
+		} `json:"signedCertificateEmbeddedSct"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("parsing fulcio response: %w", err)
+	}
+	if len(parsed.SignedCertificateEmbeddedSct.Chain) == 0 {
+		return nil, fmt.Errorf("fulcio response contained no certificate chain")
+	}
+
+	leafBlock, _ := pem.Decode([]byte(parsed.SignedCertificateEmbeddedSct.Chain[0]))
+	if leafBlock == nil {
+		return nil, fmt.Errorf("fulcio returned an unparseable certificate")
+	}
+	return leafBlock.Bytes, nil
+}
+
+func recordRekorEntry(rekorURL string, sig, cert, payload []byte) (*tlogEntry, error) {
+	digest := sha256.Sum256(payload)
+	reqBody := map[string]interface{}{
+		"kind":       "hashedrekord",
+		"apiVersion": "0.0.1",
+		"spec": map[string]interface{}{
+			"signature": map[string]interface{}{
+				"content":   base64.StdEncoding.EncodeToString(sig),
+				"publicKey": map[string]string{"content": base64.StdEncoding.EncodeToString(cert)},
+			},
+			"data": map[string]interface{}{
+				"hash": map[string]string{
+					"algorithm": "sha256",
+					"value":     fmt.Sprintf("%x", digest),
+				},
+			},
+		},
+	}
+	b, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling rekor entry: %w", err)
+	}
+
+	resp, err := http.Post(rekorURL+"/api/v1/log/entries", "application/json", bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("calling rekor: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading rekor response: %w", err)
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rekor returned %d: %s", resp.StatusCode, body)
+	}
+
+	var parsed map[string]struct {
+		LogIndex       int64  `json:"logIndex"`
+		LogID          string `json:"logID"`
+		IntegratedTime int64  `json:"integratedTime"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("parsing rekor response: %w", err)
+	}
+	for _, v := range parsed {
+		return &tlogEntry{
+			URL:        rekorURL,
+			LogIndex:   v.LogIndex,
+			LogID:      v.LogID,
+			IntegrateD: time.Unix(v.IntegratedTime, 0).UTC().Format(time.RFC3339),
+		}, nil
+	}
+	return nil, fmt.Errorf("rekor response contained no entries")
+}
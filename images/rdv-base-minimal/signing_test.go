@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// writeTestPGPKey generates an armored PGP entity (public and private key
+// in one block, as openpgp.ReadKeyRing expects) and returns its path.
+func writeTestPGPKey(t *testing.T) string {
+	t.Helper()
+	entity, err := openpgp.NewEntity("rdv-test", "", "rdv-test@example.com", nil)
+	if err != nil {
+		t.Fatalf("generating pgp entity: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatalf("opening armor writer: %v", err)
+	}
+	if err := entity.SerializePrivate(w, nil); err != nil {
+		t.Fatalf("serializing pgp entity: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("closing armor writer: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.asc")
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatalf("writing pgp key: %v", err)
+	}
+	return path
+}
+
+func TestSignVerifyPGPRoundTrip(t *testing.T) {
+	keyPath := writeTestPGPKey(t)
+	t.Setenv("TOOL_SIGNING_MODE", "pgp")
+	t.Setenv("TOOL_SIGNING_KEY", keyPath)
+	t.Setenv("TOOL_VERIFY_KEY", keyPath)
+
+	payload := []byte(`{"hello":"world"}`)
+	sig, err := signAttestation(payload)
+	if err != nil {
+		t.Fatalf("signAttestation: %v", err)
+	}
+	s, ok := sig.(*signature)
+	if !ok || s.Mode != "pgp" {
+		t.Fatalf("signAttestation returned %#v, want a pgp *signature", sig)
+	}
+
+	if err := verifyPGP(payload, s); err != nil {
+		t.Fatalf("verifyPGP: %v", err)
+	}
+	if err := verifyPGP(append(payload, 'x'), s); err == nil {
+		t.Fatal("verifyPGP accepted a tampered payload")
+	}
+}
+
+// writeTestKeyAndCert generates a self-signed certificate for pub/priv
+// and writes both the PKCS8 private key and the certificate as PEM
+// files, returning their paths.
+func writeTestKeyAndCert(t *testing.T, priv, pub any) (keyPath, certPath string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("marshaling private key: %v", err)
+	}
+	keyPath = filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("writing private key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "rdv-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, pub, priv)
+	if err != nil {
+		t.Fatalf("creating self-signed certificate: %v", err)
+	}
+	certPath = filepath.Join(t.TempDir(), "cert.pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0600); err != nil {
+		t.Fatalf("writing certificate: %v", err)
+	}
+	return keyPath, certPath
+}
+
+func TestSignVerifyX509ECDSARoundTrip(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ecdsa key: %v", err)
+	}
+	keyPath, certPath := writeTestKeyAndCert(t, priv, &priv.PublicKey)
+
+	t.Setenv("TOOL_SIGNING_MODE", "x509")
+	t.Setenv("TOOL_SIGNING_KEY", keyPath)
+	t.Setenv("TOOL_SIGNING_CERT_CHAIN", certPath)
+
+	payload := []byte(`{"hello":"world"}`)
+	sig, err := signAttestation(payload)
+	if err != nil {
+		t.Fatalf("signAttestation: %v", err)
+	}
+	s, ok := sig.(*signature)
+	if !ok || s.Mode != "x509" {
+		t.Fatalf("signAttestation returned %#v, want an x509 *signature", sig)
+	}
+
+	if err := verifyX509(payload, s); err != nil {
+		t.Fatalf("verifyX509: %v", err)
+	}
+	if err := verifyX509(append(payload, 'x'), s); err == nil {
+		t.Fatal("verifyX509 accepted a tampered payload")
+	}
+}
+
+func TestSignVerifyX509Ed25519RoundTrip(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ed25519 key: %v", err)
+	}
+	keyPath, certPath := writeTestKeyAndCert(t, priv, pub)
+
+	t.Setenv("TOOL_SIGNING_MODE", "x509")
+	t.Setenv("TOOL_SIGNING_KEY", keyPath)
+	t.Setenv("TOOL_SIGNING_CERT_CHAIN", certPath)
+
+	payload := []byte(`{"hello":"world"}`)
+	sig, err := signAttestation(payload)
+	if err != nil {
+		t.Fatalf("signAttestation: %v", err)
+	}
+	s, ok := sig.(*signature)
+	if !ok || s.Algorithm != "ed25519" {
+		t.Fatalf("signAttestation returned %#v, want an ed25519 *signature", sig)
+	}
+
+	if err := verifyX509(payload, s); err != nil {
+		t.Fatalf("verifyX509: %v", err)
+	}
+	if err := verifyX509(append(payload, 'x'), s); err == nil {
+		t.Fatal("verifyX509 accepted a tampered payload")
+	}
+}
+
+func TestSignX509RequiresCertChain(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ecdsa key: %v", err)
+	}
+	keyPath, _ := writeTestKeyAndCert(t, priv, &priv.PublicKey)
+
+	t.Setenv("TOOL_SIGNING_MODE", "x509")
+	t.Setenv("TOOL_SIGNING_KEY", keyPath)
+
+	if _, err := signAttestation([]byte("payload")); err == nil {
+		t.Fatal("signAttestation succeeded without TOOL_SIGNING_CERT_CHAIN, producing a signature verifyX509 can never check")
+	}
+}
+
+func TestSignX509WrongKeyFailsVerification(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating ecdsa key: %v", err)
+	}
+	keyPath, _ := writeTestKeyAndCert(t, priv, &priv.PublicKey)
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating other ecdsa key: %v", err)
+	}
+	_, otherCertPath := writeTestKeyAndCert(t, other, &other.PublicKey)
+
+	t.Setenv("TOOL_SIGNING_MODE", "x509")
+	t.Setenv("TOOL_SIGNING_KEY", keyPath)
+	t.Setenv("TOOL_SIGNING_CERT_CHAIN", otherCertPath)
+
+	payload := []byte(`{"hello":"world"}`)
+	sig, err := signAttestation(payload)
+	if err != nil {
+		t.Fatalf("signAttestation: %v", err)
+	}
+	s := sig.(*signature)
+
+	if err := verifyX509(payload, s); err == nil {
+		t.Fatal("verifyX509 accepted a signature against a certificate for a different key")
+	}
+}
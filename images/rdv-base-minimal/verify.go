@@ -0,0 +1,187 @@
+// rdv verify: checks the signature embedded in a .attestation.json file.
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// cmdVerify implements `rdv verify [path-to-.attestation.json]`. It
+// defaults to ./.attestation.json and returns a non-nil error if the
+// attestation is unsigned, the signature does not parse, or it fails to
+// verify.
+func cmdVerify(args []string) error {
+	path := ".attestation.json"
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	// A DSSE envelope (TOOL_ATTESTATION_FORMAT=in-toto) shares no fields
+	// with the native attestation struct, so detect it up front.
+	var probe struct {
+		PayloadType string `json:"payloadType"`
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if probe.PayloadType != "" {
+		return verifyDSSE(path, raw)
+	}
+
+	var att attestation
+	if err := json.Unmarshal(raw, &att); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if att.Signature == nil {
+		return fmt.Errorf("%s has no signature", path)
+	}
+
+	sigJSON, err := json.Marshal(att.Signature)
+	if err != nil {
+		return fmt.Errorf("re-marshaling signature: %w", err)
+	}
+	var sig signature
+	if err := json.Unmarshal(sigJSON, &sig); err != nil {
+		return fmt.Errorf("parsing signature: %w", err)
+	}
+
+	unsigned := att
+	unsigned.Signature = nil
+	payload, err := json.MarshalIndent(unsigned, "", "  ")
+	if err != nil {
+		return fmt.Errorf("re-marshaling unsigned attestation: %w", err)
+	}
+
+	if err := verifySignature(payload, &sig); err != nil {
+		return fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	fmt.Printf("OK: %s signature (%s) verified\n", sig.Mode, sig.Algorithm)
+	if sig.Tlog != nil {
+		fmt.Printf("OK: transparency log entry %s at index %d\n", sig.Tlog.LogID, sig.Tlog.LogIndex)
+	}
+	return nil
+}
+
+func verifyDSSE(path string, raw []byte) error {
+	var env dsseEnvelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return fmt.Errorf("parsing %s as a DSSE envelope: %w", path, err)
+	}
+	if len(env.Signatures) == 0 {
+		return fmt.Errorf("%s has no signatures", path)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return fmt.Errorf("decoding DSSE payload: %w", err)
+	}
+	pae := preAuthenticationEncoding(env.PayloadType, payload)
+
+	for _, s := range env.Signatures {
+		sig := signature{Mode: s.Mode, Algorithm: s.Algorithm, Value: s.Sig, Certificate: s.Certificate, Tlog: s.Tlog}
+		if err := verifySignature(pae, &sig); err != nil {
+			return fmt.Errorf("signature verification failed: %w", err)
+		}
+		fmt.Printf("OK: %s signature (%s) verified (DSSE envelope)\n", sig.Mode, sig.Algorithm)
+		if sig.Tlog != nil {
+			fmt.Printf("OK: transparency log entry %s at index %d\n", sig.Tlog.LogID, sig.Tlog.LogIndex)
+		}
+	}
+	return nil
+}
+
+func verifySignature(signed []byte, sig *signature) error {
+	switch sig.Mode {
+	case "pgp":
+		return verifyPGP(signed, sig)
+	case "x509", "keyless":
+		return verifyX509(signed, sig)
+	default:
+		return fmt.Errorf("unknown signature mode: %s", sig.Mode)
+	}
+}
+
+func verifyPGP(payload []byte, sig *signature) error {
+	keyPath := os.Getenv("TOOL_VERIFY_KEY")
+	if keyPath == "" {
+		return fmt.Errorf("TOOL_VERIFY_KEY not set")
+	}
+	f, err := os.Open(keyPath)
+	if err != nil {
+		return fmt.Errorf("opening TOOL_VERIFY_KEY: %w", err)
+	}
+	defer f.Close()
+
+	block, err := armor.Decode(f)
+	if err != nil {
+		return fmt.Errorf("decoding armored key: %w", err)
+	}
+	keyring, err := openpgp.ReadKeyRing(block.Body)
+	if err != nil {
+		return fmt.Errorf("reading pgp keyring: %w", err)
+	}
+
+	sigBytes, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return fmt.Errorf("decoding signature value: %w", err)
+	}
+
+	_, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(payload), bytes.NewReader(sigBytes), nil)
+	return err
+}
+
+func verifyX509(payload []byte, sig *signature) error {
+	if sig.Certificate == "" {
+		return fmt.Errorf("signature has no embedded certificate")
+	}
+	certDER, err := base64.StdEncoding.DecodeString(sig.Certificate)
+	if err != nil {
+		return fmt.Errorf("decoding certificate: %w", err)
+	}
+	// The embedded value may be a whole chain of concatenated DER
+	// certificates; the first one is the signing certificate.
+	certs, err := x509.ParseCertificates(certDER)
+	if err != nil {
+		return fmt.Errorf("parsing certificate: %w", err)
+	}
+	if len(certs) == 0 {
+		return fmt.Errorf("signature has no embedded certificate")
+	}
+	cert := certs[0]
+
+	sigBytes, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return fmt.Errorf("decoding signature value: %w", err)
+	}
+
+	switch pub := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(payload)
+		if !ecdsa.VerifyASN1(pub, digest[:], sigBytes) {
+			return fmt.Errorf("ecdsa signature does not match")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, payload, sigBytes) {
+			return fmt.Errorf("ed25519 signature does not match")
+		}
+	default:
+		return fmt.Errorf("unsupported certificate public key type %T", pub)
+	}
+	return nil
+}
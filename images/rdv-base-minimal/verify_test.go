@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCmdVerifyNativeRoundTrip(t *testing.T) {
+	keyPath := writeTestPGPKey(t)
+	t.Setenv("TOOL_SIGNING_MODE", "pgp")
+	t.Setenv("TOOL_SIGNING_KEY", keyPath)
+	t.Setenv("TOOL_VERIFY_KEY", keyPath)
+
+	att := &attestation{SpecVersion: specVersion, Builder: attestationBuilder{ID: "rdv-local", TrustLevel: "local"}}
+	b, err := nativeAttestationBytes(att, "test-tool", "inv-1")
+	if err != nil {
+		t.Fatalf("nativeAttestationBytes: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), ".attestation.json")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatalf("writing attestation: %v", err)
+	}
+
+	if err := cmdVerify([]string{path}); err != nil {
+		t.Fatalf("cmdVerify: %v", err)
+	}
+}
+
+func TestCmdVerifyRejectsTamperedAttestation(t *testing.T) {
+	keyPath := writeTestPGPKey(t)
+	t.Setenv("TOOL_SIGNING_MODE", "pgp")
+	t.Setenv("TOOL_SIGNING_KEY", keyPath)
+	t.Setenv("TOOL_VERIFY_KEY", keyPath)
+
+	att := &attestation{SpecVersion: specVersion, Builder: attestationBuilder{ID: "rdv-local", TrustLevel: "local"}}
+	b, err := nativeAttestationBytes(att, "test-tool", "inv-1")
+	if err != nil {
+		t.Fatalf("nativeAttestationBytes: %v", err)
+	}
+
+	var tampered attestation
+	if err := json.Unmarshal(b, &tampered); err != nil {
+		t.Fatalf("unmarshaling attestation: %v", err)
+	}
+	tampered.Builder.ID = "rdv-tampered"
+	b, err = json.MarshalIndent(tampered, "", "  ")
+	if err != nil {
+		t.Fatalf("re-marshaling tampered attestation: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), ".attestation.json")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatalf("writing tampered attestation: %v", err)
+	}
+
+	if err := cmdVerify([]string{path}); err == nil {
+		t.Fatal("cmdVerify accepted a tampered attestation")
+	}
+}
+
+func TestCmdVerifyDSSEEnvelope(t *testing.T) {
+	keyPath := writeTestPGPKey(t)
+	t.Setenv("TOOL_SIGNING_MODE", "pgp")
+	t.Setenv("TOOL_SIGNING_KEY", keyPath)
+	t.Setenv("TOOL_VERIFY_KEY", keyPath)
+
+	att := attestation{
+		SpecVersion: specVersion,
+		Builder:     attestationBuilder{ID: "rdv-local", TrustLevel: "local"},
+		Products:    map[string]attestationProduct{"out.bin": {SHA256: "ab", Path: "out.bin"}},
+	}
+	b, err := inTotoAttestationBytes(att, nil, "test-tool", "inv-1")
+	if err != nil {
+		t.Fatalf("inTotoAttestationBytes: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), ".attestation.json")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatalf("writing DSSE envelope: %v", err)
+	}
+
+	if err := cmdVerify([]string{path}); err != nil {
+		t.Fatalf("cmdVerify: %v", err)
+	}
+}
+
+func TestCmdVerifyUnsignedAttestation(t *testing.T) {
+	att := attestation{SpecVersion: specVersion, Builder: attestationBuilder{ID: "rdv-local", TrustLevel: "local"}}
+	b, err := json.MarshalIndent(att, "", "  ")
+	if err != nil {
+		t.Fatalf("marshaling attestation: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), ".attestation.json")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatalf("writing attestation: %v", err)
+	}
+
+	if err := cmdVerify([]string{path}); err == nil {
+		t.Fatal("cmdVerify accepted an unsigned attestation")
+	}
+}